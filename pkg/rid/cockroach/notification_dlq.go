@@ -0,0 +1,53 @@
+package cockroach
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	dssmodels "github.com/interuss/dss/pkg/models"
+	"github.com/interuss/dss/pkg/rid/notifier"
+
+	dssql "github.com/interuss/dss/pkg/sql"
+)
+
+const notificationDLQFields = "owner, subscription_id, url, payload, first_attempt, last_error, retries"
+
+// NotificationDLQStore is a CRDB-backed notifier.DLQ that persists
+// notifications which exhausted their retry budget so operators can inspect
+// and replay them.
+type NotificationDLQStore struct {
+	dssql.Queryable
+}
+
+var _ notifier.DLQ = (*NotificationDLQStore)(nil)
+
+// Put implements notifier.DLQ.
+func (c *NotificationDLQStore) Put(ctx context.Context, owner dssmodels.Owner, n *notifier.Notification, lastErr error, retries int) error {
+	var query = fmt.Sprintf(`
+		INSERT INTO
+			subscription_notification_dlq
+			(%s)
+		VALUES
+			($1, $2, $3, $4, $5, $6, $7)`, notificationDLQFields)
+
+	payload, err := json.Marshal(n.Payload)
+	if err != nil {
+		return fmt.Errorf("marshaling dead-lettered payload: %w", err)
+	}
+
+	var lastErrMsg string
+	if lastErr != nil {
+		lastErrMsg = lastErr.Error()
+	}
+
+	_, err = c.ExecContext(ctx, query,
+		owner,
+		n.SubscriptionID,
+		n.URL,
+		payload,
+		n.FirstAttempt,
+		lastErrMsg,
+		retries)
+	return err
+}
@@ -3,13 +3,20 @@ package cockroach
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/dpjacques/clockwork"
 	dsserr "github.com/interuss/dss/pkg/errors"
 	"github.com/interuss/dss/pkg/geo"
 	dssmodels "github.com/interuss/dss/pkg/models"
 	ridmodels "github.com/interuss/dss/pkg/rid/models"
+	"github.com/interuss/dss/pkg/rid/notifier"
+	"github.com/interuss/dss/pkg/rid/repos/cache"
 
 	"github.com/golang/geo/s2"
 	dssql "github.com/interuss/dss/pkg/sql"
@@ -18,7 +25,22 @@ import (
 )
 
 const (
-	subscriptionFields = "id, owner, url, notification_index, cells, starts_at, ends_at, updated_at"
+	subscriptionFields = "id, owner, url, notification_index, cells, starts_at, ends_at, updated_at, filter"
+
+	// defaultSubscriptionPageSize is used by SearchSubscriptionsPage when
+	// the caller doesn't request a specific page size.
+	defaultSubscriptionPageSize = 100
+
+	// cellShardSize bounds how many s2 cells go into a single
+	// SearchSubscriptionsPage shard query. Splitting a large CellUnion this
+	// way keeps each query's `cells && $1` probe small and lets shards run
+	// concurrently instead of one slow sequential scan over a huge array.
+	cellShardSize = 64
+
+	// maxConcurrentShardQueries bounds how many shard queries
+	// SearchSubscriptionsPage runs at once, so a search over a very large
+	// CellUnion can't flood the connection pool with one query per shard.
+	maxConcurrentShardQueries = 8
 )
 
 // SubscriptionStore is an implementation of the SubscriptionRepo for CRDB.
@@ -27,6 +49,46 @@ type SubscriptionStore struct {
 
 	clock  clockwork.Clock
 	logger *zap.Logger
+
+	// dispatcher delivers ISA change notifications to subscribers after
+	// their notification_index has been bumped. It may be nil, in which
+	// case UpdateNotificationIdxsInCells only mutates the rows and leaves
+	// delivery to the caller.
+	dispatcher notifier.NotificationDispatcher
+
+	// subCounter, when non-nil, answers MaxSubscriptionCountInCellsByOwner
+	// from memory instead of the CRDB aggregation query below. In ModeMemory
+	// it is kept up to date by the Insert/Update/Delete paths plus a
+	// periodic reconcile; in ModeChangefeed, by the CHANGEFEED alone (see
+	// countInline). See --sub-count-cache.
+	subCounter     *cache.SubscriptionCounter
+	subCounterMode cache.Mode
+}
+
+// NewSubscriptionStore returns a SubscriptionStore backed by q. Pass a nil
+// dispatcher to leave ISA change notification delivery to the caller, and
+// ModeOff (or a nil subCounter) to always serve
+// MaxSubscriptionCountInCellsByOwner from the CRDB aggregation query instead
+// of subCounter. See cache.NewCounterFromDB for building subCounter.
+func NewSubscriptionStore(q dssql.Queryable, clock clockwork.Clock, logger *zap.Logger, dispatcher notifier.NotificationDispatcher, subCounter *cache.SubscriptionCounter, subCounterMode cache.Mode) *SubscriptionStore {
+	return &SubscriptionStore{
+		Queryable:      q,
+		clock:          clock,
+		logger:         logger,
+		dispatcher:     dispatcher,
+		subCounter:     subCounter,
+		subCounterMode: subCounterMode,
+	}
+}
+
+// countInline reports whether Insert/Update/DeleteSubscription should
+// mutate subCounter themselves. In ModeChangefeed, the CHANGEFEED this node
+// subscribes to re-emits its own row changes, so applyChangefeedRow already
+// accounts for every local write; mutating subCounter inline as well would
+// double-count it. ModeMemory has no such feed, so it's the only mode where
+// these paths are subCounter's sole updater between reconciles.
+func (c *SubscriptionStore) countInline() bool {
+	return c.subCounter != nil && c.subCounterMode != cache.ModeChangefeed
 }
 
 // process a query that should return one or many subscriptions.
@@ -42,6 +104,7 @@ func (c *SubscriptionStore) process(ctx context.Context, query string, args ...i
 
 	for rows.Next() {
 		s := new(ridmodels.Subscription)
+		s.Filter = new(ridmodels.Filter)
 
 		err := rows.Scan(
 			&s.ID,
@@ -52,6 +115,7 @@ func (c *SubscriptionStore) process(ctx context.Context, query string, args ...i
 			&s.StartTime,
 			&s.EndTime,
 			&s.Version,
+			s.Filter,
 		)
 		if err != nil {
 			return nil, err
@@ -84,9 +148,10 @@ func (c *SubscriptionStore) processOne(ctx context.Context, query string, args .
 // owner has in each one of these cells, and returns the number of subscriptions
 // in the cell with the highest number of subscriptions.
 func (c *SubscriptionStore) MaxSubscriptionCountInCellsByOwner(ctx context.Context, cells s2.CellUnion, owner dssmodels.Owner) (int, error) {
-	// TODO:steeling this query is expensive. The standard defines the max sub
-	// per "area", but area is loosely defined. Since we may not have to be so
-	// strict we could keep this count in memory, (or in some other storage).
+	if c.subCounterMode != cache.ModeOff && c.subCounter != nil {
+		return int(c.subCounter.Max(owner, cells)), nil
+	}
+
 	var query = `
     SELECT
       IFNULL(MAX(subscriptions_per_cell_id), 0)
@@ -124,9 +189,233 @@ func (c *SubscriptionStore) GetSubscription(ctx context.Context, id dssmodels.ID
 	return c.processOne(ctx, query, id)
 }
 
-// UpdateSubscription updates the Subscription.. not yet implemented.
-func (c *SubscriptionStore) UpdateSubscription(ctx context.Context, s *ridmodels.Subscription) (*ridmodels.Subscription, error) {
-	return nil, dsserr.Internal("not yet implemented")
+// SubscriptionFieldMask selects which fields of s UpdateSubscription should
+// apply. Unset fields are left untouched, so callers can PATCH a
+// Subscription instead of always overwriting every column.
+type SubscriptionFieldMask struct {
+	URL       bool
+	StartTime bool
+	EndTime   bool
+	Cells     bool
+	Filter    bool
+}
+
+// txBeginner is satisfied by *sql.DB but not *sql.Tx, letting
+// UpdateSubscription open its own transaction when c isn't already
+// tx-scoped by a caller.
+type txBeginner interface {
+	BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error)
+}
+
+// UpdateSubscription applies the fields selected by mask from s onto the
+// subscription identified by s.ID, using the updated_at-based optimistic
+// lock: the call fails with dsserr.VersionMismatch if the row has been
+// modified since s.Version was read. When mask.Cells is set, the change in
+// cell membership is computed against the current row and
+// notification_index is bumped for every subscription touching an added or
+// removed cell, atomically with the mutation.
+func (c *SubscriptionStore) UpdateSubscription(ctx context.Context, s *ridmodels.Subscription, mask SubscriptionFieldMask) (*ridmodels.Subscription, error) {
+	beginner, ok := c.Queryable.(txBeginner)
+	if !ok {
+		// Already running inside a caller-managed transaction; this store
+		// doesn't own that commit and can't defer past it, so apply
+		// immediately once the mutation itself succeeds, the same as every
+		// other mutation in this file (Insert/DeleteSubscription).
+		ret, pending, err := c.updateSubscriptionInTx(ctx, s, mask)
+		if err != nil {
+			return nil, err
+		}
+		pending.Apply(ctx)
+		return ret, nil
+	}
+
+	tx, err := beginner.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	txStore := &SubscriptionStore{
+		Queryable:      tx,
+		clock:          c.clock,
+		logger:         c.logger,
+		dispatcher:     c.dispatcher,
+		subCounter:     c.subCounter,
+		subCounterMode: c.subCounterMode,
+	}
+	ret, pending, err := txStore.updateSubscriptionInTx(ctx, s, mask)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	// Apply only now that the mutation pending captures is durably
+	// committed, so a subsequent rollback can never dispatch a notification
+	// or adjust subCounter for a change that didn't actually happen.
+	pending.Apply(ctx)
+	return ret, nil
+}
+
+// pendingSubscriptionUpdate captures the notification dispatch and/or
+// subCounter delta computed by updateSubscriptionInTx, staged until the
+// transaction that computed them durably commits. Call Apply only after
+// that commit succeeds.
+type pendingSubscriptionUpdate struct {
+	store *SubscriptionStore
+
+	notifySubs  []*ridmodels.Subscription
+	notifyCells s2.CellUnion
+
+	counterOwner    dssmodels.Owner
+	counterOldCells s2.CellUnion
+	counterNewCells s2.CellUnion
+	hasCounterDelta bool
+}
+
+// Apply dispatches any staged notification and applies any staged
+// subCounter delta. u may be nil, in which case Apply is a no-op. Call this
+// only after the transaction that produced u has committed.
+func (u *pendingSubscriptionUpdate) Apply(ctx context.Context) {
+	if u == nil {
+		return
+	}
+	if len(u.notifySubs) > 0 {
+		u.store.dispatchNotifications(ctx, u.notifySubs, u.notifyCells, nil)
+	}
+	if u.hasCounterDelta && u.store.countInline() {
+		u.store.subCounter.OnUpdate(u.counterOwner, u.counterOldCells, u.counterNewCells)
+	}
+}
+
+func (c *SubscriptionStore) updateSubscriptionInTx(ctx context.Context, s *ridmodels.Subscription, mask SubscriptionFieldMask) (*ridmodels.Subscription, *pendingSubscriptionUpdate, error) {
+	var getQuery = fmt.Sprintf(`
+		SELECT %s FROM subscriptions
+		WHERE id = $1
+		FOR UPDATE`, subscriptionFields)
+
+	old, err := c.processOne(ctx, getQuery, s.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if !old.Version.ToTimestamp().Equal(s.Version.ToTimestamp()) {
+		return nil, nil, dsserr.VersionMismatch("subscription has been modified since it was last read")
+	}
+
+	next := *old
+	if mask.URL {
+		next.URL = s.URL
+	}
+	if mask.StartTime {
+		next.StartTime = s.StartTime
+	}
+	if mask.EndTime {
+		next.EndTime = s.EndTime
+	}
+	if mask.Filter {
+		next.Filter = s.Filter
+	}
+
+	var added, removed s2.CellUnion
+	if mask.Cells {
+		for _, cell := range s.Cells {
+			if err := geo.ValidateCell(cell); err != nil {
+				return nil, nil, err
+			}
+		}
+		added, removed = cellDiff(old.Cells, s.Cells)
+		next.Cells = s.Cells
+	}
+
+	var (
+		updateQuery = fmt.Sprintf(`
+			UPDATE subscriptions
+			SET (%s) = ($1, $2, $3, $4, $5, $6, $7, transaction_timestamp(), $8)
+			WHERE id = $1 AND updated_at = $9
+			RETURNING %s`, subscriptionFields, subscriptionFields)
+	)
+
+	cids := make([]int64, len(next.Cells))
+	for i, cell := range next.Cells {
+		cids[i] = int64(cell)
+	}
+
+	ret, err := c.processOne(ctx, updateQuery,
+		next.ID,
+		next.Owner,
+		next.URL,
+		next.NotificationIndex,
+		pq.Int64Array(cids),
+		next.StartTime,
+		next.EndTime,
+		filterOrEmpty(next.Filter),
+		s.Version.ToTimestamp())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var pending *pendingSubscriptionUpdate
+	if touched := append(append(s2.CellUnion{}, added...), removed...); len(touched) > 0 {
+		// The subscriber set in touched changed, not an ISA/flight itself, so
+		// there's no event to filter notifications against. The bump itself
+		// must happen here, inside the transaction, but dispatch waits for
+		// the caller to commit - see UpdateSubscription.
+		subs, err := c.bumpNotificationIdxsInCells(ctx, touched)
+		if err != nil {
+			return nil, nil, err
+		}
+		pending = &pendingSubscriptionUpdate{store: c, notifySubs: subs, notifyCells: touched}
+	}
+
+	if mask.Cells && c.countInline() {
+		// Staged rather than applied here: c.subCounter is shared, in-memory
+		// state that isn't part of the transaction, so mutating it before
+		// tx.Commit succeeds would leave it permanently drifted if the
+		// commit then failed (e.g. a serialization retry).
+		if pending == nil {
+			pending = &pendingSubscriptionUpdate{store: c}
+		}
+		pending.counterOwner = ret.Owner
+		pending.counterOldCells = old.Cells
+		pending.counterNewCells = ret.Cells
+		pending.hasCounterDelta = true
+	}
+
+	return ret, pending, nil
+}
+
+// filterOrEmpty returns f, or an empty Filter if f is nil, so the value
+// bound for the NOT NULL filter column is always a non-nil *Filter that
+// encodes to a JSON object.
+func filterOrEmpty(f *ridmodels.Filter) *ridmodels.Filter {
+	if f == nil {
+		return &ridmodels.Filter{}
+	}
+	return f
+}
+
+// cellDiff returns the cells present in next but not old (added) and the
+// cells present in old but not next (removed).
+func cellDiff(old, next s2.CellUnion) (added, removed s2.CellUnion) {
+	oldSet := make(map[s2.CellID]bool, len(old))
+	for _, c := range old {
+		oldSet[c] = true
+	}
+	nextSet := make(map[s2.CellID]bool, len(next))
+	for _, c := range next {
+		nextSet[c] = true
+		if !oldSet[c] {
+			added = append(added, c)
+		}
+	}
+	for _, c := range old {
+		if !nextSet[c] {
+			removed = append(removed, c)
+		}
+	}
+	return added, removed
 }
 
 // InsertSubscription inserts subscription into the store and returns
@@ -136,8 +425,8 @@ func (c *SubscriptionStore) InsertSubscription(ctx context.Context, s *ridmodels
 		udpateQuery = fmt.Sprintf(`
 		UPDATE
 		  subscriptions
-		SET (%s) = ($1, $2, $3, $4, $5, $6, $7, transaction_timestamp())
-		WHERE id = $1 AND updated_at = $8
+		SET (%s) = ($1, $2, $3, $4, $5, $6, $7, transaction_timestamp(), $8)
+		WHERE id = $1 AND updated_at = $9
 		RETURNING
 			%s`, subscriptionFields, subscriptionFields)
 		insertQuery = fmt.Sprintf(`
@@ -145,7 +434,7 @@ func (c *SubscriptionStore) InsertSubscription(ctx context.Context, s *ridmodels
 		  subscriptions
 		  (%s)
 		VALUES
-			($1, $2, $3, $4, $5, $6, $7, transaction_timestamp())
+			($1, $2, $3, $4, $5, $6, $7, transaction_timestamp(), $8)
 		RETURNING
 			%s`, subscriptionFields, subscriptionFields)
 	)
@@ -163,15 +452,6 @@ func (c *SubscriptionStore) InsertSubscription(ctx context.Context, s *ridmodels
 	var ret *ridmodels.Subscription
 	if s.Version.Empty() {
 		ret, err = c.processOne(ctx, insertQuery,
-			s.ID,
-			s.Owner,
-			s.URL,
-			s.NotificationIndex,
-			pq.Int64Array(cids),
-			s.StartTime,
-			s.EndTime)
-	} else {
-		ret, err = c.processOne(ctx, udpateQuery,
 			s.ID,
 			s.Owner,
 			s.URL,
@@ -179,7 +459,38 @@ func (c *SubscriptionStore) InsertSubscription(ctx context.Context, s *ridmodels
 			pq.Int64Array(cids),
 			s.StartTime,
 			s.EndTime,
-			s.Version.ToTimestamp())
+			filterOrEmpty(s.Filter))
+		if err == nil && c.countInline() {
+			c.subCounter.OnInsert(ret.Owner, ret.Cells)
+		}
+		return ret, err
+	}
+
+	// This is a replace-by-version upsert rather than an insert, so the
+	// counter needs the cells the row had before this write, not just the
+	// ones it ends up with: crediting ret.Cells alone (as if this were an
+	// insert) would double-count every cell the subscription already had.
+	var oldCells s2.CellUnion
+	if c.countInline() {
+		old, err := c.GetSubscription(ctx, s.ID)
+		if err != nil {
+			return nil, err
+		}
+		oldCells = old.Cells
+	}
+
+	ret, err = c.processOne(ctx, udpateQuery,
+		s.ID,
+		s.Owner,
+		s.URL,
+		s.NotificationIndex,
+		pq.Int64Array(cids),
+		s.StartTime,
+		s.EndTime,
+		filterOrEmpty(s.Filter),
+		s.Version.ToTimestamp())
+	if err == nil && c.countInline() {
+		c.subCounter.OnUpdate(ret.Owner, oldCells, ret.Cells)
 	}
 	return ret, err
 }
@@ -197,11 +508,43 @@ func (c *SubscriptionStore) DeleteSubscription(ctx context.Context, s *ridmodels
 			AND updated_at = $3
 		RETURNING %s`, subscriptionFields)
 	)
-	return c.processOne(ctx, query, s.ID, s.Owner, s.Version.ToTimestamp())
+	ret, err := c.processOne(ctx, query, s.ID, s.Owner, s.Version.ToTimestamp())
+	if err == nil && c.countInline() {
+		c.subCounter.OnDelete(ret.Owner, ret.Cells)
+	}
+	return ret, err
 }
 
-// UpdateNotificationIdxsInCells incremement the notification for each sub in the given cells.
-func (c *SubscriptionStore) UpdateNotificationIdxsInCells(ctx context.Context, cells s2.CellUnion) ([]*ridmodels.Subscription, error) {
+// UpdateNotificationIdxsInCells incremement the notification for each sub in
+// the given cells and dispatches accordingly. event, if non-nil, describes
+// the ISA/flight change that triggered the bump and is matched against
+// each subscription's Filter to decide who actually gets dispatched a
+// notification; pass nil when the bump isn't in response to an event
+// (e.g. a subscription's own cells changed), in which case every matching
+// subscription is notified.
+//
+// Call this only when c isn't inside a transaction that might still roll
+// back: the bump is committed per-statement as it runs, and dispatch
+// follows immediately after. A caller that does hold its own transaction
+// (e.g. updateSubscriptionInTx) must use bumpNotificationIdxsInCells
+// instead and call dispatchNotifications itself once its transaction
+// commits.
+func (c *SubscriptionStore) UpdateNotificationIdxsInCells(ctx context.Context, cells s2.CellUnion, event *ridmodels.ISAEvent) ([]*ridmodels.Subscription, error) {
+	subs, err := c.bumpNotificationIdxsInCells(ctx, cells)
+	if err != nil {
+		return nil, err
+	}
+
+	c.dispatchNotifications(ctx, subs, cells, event)
+	return subs, nil
+}
+
+// bumpNotificationIdxsInCells increments notification_index for each
+// subscription in cells, without dispatching. Split out from
+// UpdateNotificationIdxsInCells so callers that run inside their own
+// transaction can bump now and defer dispatchNotifications until after
+// they commit.
+func (c *SubscriptionStore) bumpNotificationIdxsInCells(ctx context.Context, cells s2.CellUnion) ([]*ridmodels.Subscription, error) {
 	var updateQuery = fmt.Sprintf(`
 			UPDATE subscriptions
 			SET notification_index = notification_index + 1
@@ -214,60 +557,367 @@ func (c *SubscriptionStore) UpdateNotificationIdxsInCells(ctx context.Context, c
 	for i, cell := range cells {
 		cids[i] = int64(cell)
 	}
-	return c.process(
-		ctx, updateQuery, pq.Int64Array(cids), c.clock.Now())
+	return c.process(ctx, updateQuery, pq.Int64Array(cids), c.clock.Now())
 }
 
-// SearchSubscriptions returns all subscriptions in "cells".
+// dispatchNotifications hands the subset of subs whose Filter matches event
+// off to c.dispatcher, if one is configured. Delivery happens on a detached
+// context so a slow or misbehaving subscriber can't hold up the caller;
+// failures are logged and, if configured, end up in the dead-letter queue
+// via the dispatcher itself.
+func (c *SubscriptionStore) dispatchNotifications(ctx context.Context, subs []*ridmodels.Subscription, cells s2.CellUnion, event *ridmodels.ISAEvent) {
+	if c.dispatcher == nil || len(subs) == 0 {
+		return
+	}
+
+	notify := subs[:0:0]
+	for _, s := range subs {
+		if s.Filter.Matches(event) {
+			notify = append(notify, s)
+		}
+	}
+	if len(notify) == 0 {
+		return
+	}
+
+	payload, err := json.Marshal(struct {
+		Cells s2.CellUnion `json:"cells"`
+	}{Cells: cells})
+	if err != nil {
+		c.logger.Error("marshaling isa change notification payload", zap.Error(err))
+		return
+	}
+
+	go func() {
+		detached := context.Background()
+		if errs := c.dispatcher.DispatchAll(detached, notify, payload); len(errs) > 0 {
+			for _, err := range errs {
+				c.logger.Error("dispatching isa change notification", zap.Error(err))
+			}
+		}
+	}()
+}
+
+// SearchSubscriptions returns all subscriptions in "cells". It's a thin
+// wrapper over SearchSubscriptionsStream kept for callers that haven't
+// migrated to the streaming API yet.
 func (c *SubscriptionStore) SearchSubscriptions(ctx context.Context, cells s2.CellUnion) ([]*ridmodels.Subscription, error) {
-	var (
-		query = fmt.Sprintf(`
-			SELECT
-				%s
-			FROM
-				subscriptions
-			WHERE
-				cells && $1
-			AND
-				ends_at >= $2`, subscriptionFields)
-	)
+	return collectSubscriptionStream(c.SearchSubscriptionsStream(ctx, cells, SearchSubscriptionsOpts{}))
+}
 
-	if len(cells) == 0 {
-		return nil, dsserr.BadRequest("no location provided")
+// SearchSubscriptionsByOwner returns all subscriptions in "cells" belonging
+// to owner. It's a thin wrapper over SearchSubscriptionsStream kept for
+// callers that haven't migrated to the streaming API yet.
+func (c *SubscriptionStore) SearchSubscriptionsByOwner(ctx context.Context, cells s2.CellUnion, owner dssmodels.Owner) ([]*ridmodels.Subscription, error) {
+	return collectSubscriptionStream(c.SearchSubscriptionsStream(ctx, cells, SearchSubscriptionsOpts{Owner: owner}))
+}
+
+// collectSubscriptionStream drains a SearchSubscriptionsStream into a
+// slice, for the slice-returning methods built on top of it.
+func collectSubscriptionStream(out <-chan *ridmodels.Subscription, errc <-chan error) ([]*ridmodels.Subscription, error) {
+	var subs []*ridmodels.Subscription
+	for s := range out {
+		subs = append(subs, s)
 	}
+	if err := <-errc; err != nil {
+		return nil, err
+	}
+	return subs, nil
+}
+
+// SearchSubscriptionsOpts selects a page of SearchSubscriptionsStream's
+// results via id-keyset pagination, as opposed to SearchSubscriptionsPage's
+// opaque nextPageToken.
+type SearchSubscriptionsOpts struct {
+	Owner   dssmodels.Owner
+	AfterID dssmodels.ID
+	Limit   int
+}
+
+// SearchSubscriptionsStream streams subscriptions intersecting cells. Like
+// SearchSubscriptionsPage, cells is split into shards of at most
+// cellShardSize cells, scanned concurrently; unlike SearchSubscriptionsPage,
+// each shard's rows are sent to out as soon as that shard's query returns,
+// rather than waiting on every shard first, so a caller isn't blocked on the
+// slowest shard and the full result set is never held in memory at once.
+// That means out is NOT ordered by ID: it's ordered by shard completion,
+// with each shard's own rows in ID order. Rows are deduplicated by ID across
+// shards, since a subscription whose cells span more than one shard would
+// otherwise be sent twice. The returned error channel receives at most one
+// value; both channels are closed once the stream ends, so a caller can
+// range over out and then check errc.
+func (c *SubscriptionStore) SearchSubscriptionsStream(ctx context.Context, cells s2.CellUnion, opts SearchSubscriptionsOpts) (<-chan *ridmodels.Subscription, <-chan error) {
+	out := make(chan *ridmodels.Subscription)
+	errc := make(chan error, 1)
 
+	go func() {
+		defer close(errc)
+		defer close(out)
+
+		if len(cells) == 0 {
+			errc <- dsserr.BadRequest("no location provided")
+			return
+		}
+
+		shards := shardCells(cells, cellShardSize)
+		sem := make(chan struct{}, maxConcurrentShardQueries)
+
+		streamCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		var (
+			wg   sync.WaitGroup
+			mu   sync.Mutex
+			seen = make(map[dssmodels.ID]bool)
+			sent int
+			ferr error
+		)
+		fail := func(err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			if ferr == nil {
+				ferr = err
+				cancel()
+			}
+		}
+		for _, shard := range shards {
+			shard := shard
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				sem <- struct{}{}
+				defer func() { <-sem }()
+
+				subs, err := c.searchSubscriptionsStreamShard(streamCtx, shard, opts)
+				if err != nil {
+					if streamCtx.Err() == nil {
+						fail(err)
+					}
+					return
+				}
+
+				for _, s := range subs {
+					mu.Lock()
+					if seen[s.ID] || (opts.Limit > 0 && sent >= opts.Limit) {
+						mu.Unlock()
+						continue
+					}
+					seen[s.ID] = true
+					sent++
+					mu.Unlock()
+
+					select {
+					case out <- s:
+					case <-ctx.Done():
+						fail(ctx.Err())
+						return
+					}
+				}
+			}()
+		}
+		wg.Wait()
+		if ferr != nil {
+			errc <- ferr
+		}
+	}()
+
+	return out, errc
+}
+
+// searchSubscriptionsStreamShard runs the SearchSubscriptionsStream query
+// against a single cell shard, returning subscriptions that sort after
+// opts.AfterID, optionally restricted to opts.Owner. opts.Limit, if set, is
+// NOT applied per shard: since results are merged and deduplicated across
+// shards, a per-shard LIMIT could drop rows that belong in the final
+// opts.Limit-sized result (e.g. a shard whose matches all come after
+// another shard's). Capping happens once, across shards, in
+// SearchSubscriptionsStream.
+func (c *SubscriptionStore) searchSubscriptionsStreamShard(ctx context.Context, cells s2.CellUnion, opts SearchSubscriptionsOpts) ([]*ridmodels.Subscription, error) {
 	cids := make([]int64, len(cells))
 	for i, cell := range cells {
 		cids[i] = int64(cell)
 	}
 
-	return c.process(ctx, query, pq.Int64Array(cids), c.clock.Now())
+	args := []interface{}{pq.Int64Array(cids), c.clock.Now()}
+	clauses := []string{"cells && $1", "ends_at >= $2"}
+	if opts.Owner != "" {
+		args = append(args, opts.Owner)
+		clauses = append(clauses, fmt.Sprintf("subscriptions.owner = $%d", len(args)))
+	}
+	if opts.AfterID != "" {
+		args = append(args, opts.AfterID)
+		clauses = append(clauses, fmt.Sprintf("id > $%d", len(args)))
+	}
+
+	query := fmt.Sprintf(`
+		SELECT
+			%s
+		FROM
+			subscriptions
+		WHERE
+			%s
+		ORDER BY
+			id`, subscriptionFields, strings.Join(clauses, " AND "))
+
+	return c.process(ctx, query, args...)
 }
 
-// SearchSubscriptionsByOwner returns all subscriptions in "cells".
-func (c *SubscriptionStore) SearchSubscriptionsByOwner(ctx context.Context, cells s2.CellUnion, owner dssmodels.Owner) ([]*ridmodels.Subscription, error) {
+// SearchSubscriptionsPage returns up to pageSize subscriptions intersecting
+// cells, ordered by (updated_at, id) for stable keyset pagination. Pass
+// pageToken from a previous call's return value to fetch the page after
+// it; pass "" to start from the beginning. An empty returned
+// nextPageToken means there are no more results.
+//
+// cells is split into shards of at most cellShardSize cells, scanned
+// concurrently, since CRDB's inverted index on the cells column degrades
+// on a single large OVERLAPS array scanned sequentially. Each shard is
+// limited to pageSize rows itself, so a shard whose matches outnumber
+// pageSize can make the merged page require more than one round trip
+// before nextPageToken advances past it; correctness over cell boundaries
+// matters more here than round-trip count.
+func (c *SubscriptionStore) SearchSubscriptionsPage(ctx context.Context, cells s2.CellUnion, pageSize int, pageToken string) ([]*ridmodels.Subscription, string, error) {
+	if len(cells) == 0 {
+		return nil, "", dsserr.BadRequest("no location provided")
+	}
+	if pageSize <= 0 {
+		pageSize = defaultSubscriptionPageSize
+	}
+
+	var after *ridmodels.SubscriptionPageToken
+	if pageToken != "" {
+		var err error
+		after, err = ridmodels.DecodeSubscriptionPageToken(pageToken)
+		if err != nil {
+			return nil, "", dsserr.BadRequest(fmt.Sprintf("invalid page token: %s", err))
+		}
+	}
+
+	shards := shardCells(cells, cellShardSize)
+	sem := make(chan struct{}, maxConcurrentShardQueries)
+
 	var (
-		query = fmt.Sprintf(`
-			SELECT
-				%s
-			FROM
-				subscriptions
-			WHERE
-				cells && $1
-			AND
-				subscriptions.owner = $2
-			AND
-				ends_at >= $3`, subscriptionFields)
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		merged []*ridmodels.Subscription
+		ferr   error
 	)
+	for _, shard := range shards {
+		shard := shard
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
 
-	if len(cells) == 0 {
-		return nil, dsserr.BadRequest("no location provided")
+			subs, err := c.searchSubscriptionsPageShard(ctx, shard, after, pageSize)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				if ferr == nil {
+					ferr = err
+				}
+				return
+			}
+			merged = append(merged, subs...)
+		}()
 	}
+	wg.Wait()
+	if ferr != nil {
+		return nil, "", ferr
+	}
+
+	merged = dedupeSortedByUpdatedAt(merged)
+	var nextPageToken string
+	if len(merged) > pageSize {
+		merged = merged[:pageSize]
+	}
+	if len(merged) == pageSize {
+		last := merged[len(merged)-1]
+		token, err := ridmodels.SubscriptionPageToken{
+			UpdatedAt: last.Version.ToTimestamp(),
+			ID:        last.ID,
+		}.Encode()
+		if err != nil {
+			return nil, "", err
+		}
+		nextPageToken = token
+	}
+
+	return merged, nextPageToken, nil
+}
+
+// searchSubscriptionsPageShard runs the SearchSubscriptionsPage query
+// against a single cell shard, returning at most limit subscriptions that
+// sort after "after" in (updated_at, id) order.
+func (c *SubscriptionStore) searchSubscriptionsPageShard(ctx context.Context, cells s2.CellUnion, after *ridmodels.SubscriptionPageToken, limit int) ([]*ridmodels.Subscription, error) {
+	var query = fmt.Sprintf(`
+		SELECT
+			%s
+		FROM
+			subscriptions
+		WHERE
+			cells && $1
+		AND
+			ends_at >= $2
+		AND
+			(updated_at, id) > ($3, $4)
+		ORDER BY
+			updated_at, id
+		LIMIT $5`, subscriptionFields)
 
 	cids := make([]int64, len(cells))
 	for i, cell := range cells {
 		cids[i] = int64(cell)
 	}
 
-	return c.process(ctx, query, pq.Int64Array(cids), owner, c.clock.Now())
+	var afterUpdatedAt time.Time
+	var afterID dssmodels.ID
+	if after != nil {
+		afterUpdatedAt = after.UpdatedAt
+		afterID = after.ID
+	}
+
+	return c.process(ctx, query, pq.Int64Array(cids), c.clock.Now(), afterUpdatedAt, afterID, limit)
+}
+
+// shardCells splits cells into shards of at most size cells each. The last
+// shard may be smaller.
+func shardCells(cells s2.CellUnion, size int) []s2.CellUnion {
+	if len(cells) <= size {
+		return []s2.CellUnion{cells}
+	}
+	shards := make([]s2.CellUnion, 0, (len(cells)+size-1)/size)
+	for i := 0; i < len(cells); i += size {
+		end := i + size
+		if end > len(cells) {
+			end = len(cells)
+		}
+		shards = append(shards, cells[i:end])
+	}
+	return shards
+}
+
+// dedupeSortedByUpdatedAt removes duplicate subscriptions across shards
+// (a subscription whose own cells span more than one shard is returned by
+// each) and sorts the remainder by (updated_at, id), matching the order
+// each shard query already returns its own rows in.
+func dedupeSortedByUpdatedAt(subs []*ridmodels.Subscription) []*ridmodels.Subscription {
+	seen := make(map[dssmodels.ID]bool, len(subs))
+	deduped := subs[:0:0]
+	for _, s := range subs {
+		if seen[s.ID] {
+			continue
+		}
+		seen[s.ID] = true
+		deduped = append(deduped, s)
+	}
+	sort.Slice(deduped, func(i, j int) bool {
+		ti, tj := deduped[i].Version.ToTimestamp(), deduped[j].Version.ToTimestamp()
+		if !ti.Equal(tj) {
+			return ti.Before(tj)
+		}
+		return deduped[i].ID.String() < deduped[j].ID.String()
+	})
+	return deduped
 }
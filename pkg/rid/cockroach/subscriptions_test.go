@@ -0,0 +1,101 @@
+package cockroach
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/golang/geo/s2"
+)
+
+func sortedCellIDs(cells s2.CellUnion) []int64 {
+	if len(cells) == 0 {
+		return nil
+	}
+	ids := make([]int64, len(cells))
+	for i, c := range cells {
+		ids[i] = int64(c)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+func TestCellDiff(t *testing.T) {
+	cases := []struct {
+		name             string
+		old, next        s2.CellUnion
+		wantAdd, wantDel []int64
+	}{
+		{
+			name:    "no change",
+			old:     s2.CellUnion{1, 2, 3},
+			next:    s2.CellUnion{1, 2, 3},
+			wantAdd: nil,
+			wantDel: nil,
+		},
+		{
+			name:    "pure insert",
+			old:     nil,
+			next:    s2.CellUnion{1, 2},
+			wantAdd: []int64{1, 2},
+			wantDel: nil,
+		},
+		{
+			name:    "pure delete",
+			old:     s2.CellUnion{1, 2},
+			next:    nil,
+			wantAdd: nil,
+			wantDel: []int64{1, 2},
+		},
+		{
+			name:    "partial overlap",
+			old:     s2.CellUnion{1, 2, 3},
+			next:    s2.CellUnion{2, 3, 4},
+			wantAdd: []int64{4},
+			wantDel: []int64{1},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			added, removed := cellDiff(c.old, c.next)
+			if got := sortedCellIDs(added); !reflect.DeepEqual(got, c.wantAdd) {
+				t.Errorf("added = %v, want %v", got, c.wantAdd)
+			}
+			if got := sortedCellIDs(removed); !reflect.DeepEqual(got, c.wantDel) {
+				t.Errorf("removed = %v, want %v", got, c.wantDel)
+			}
+		})
+	}
+}
+
+func TestShardCellsUnderSize(t *testing.T) {
+	cells := s2.CellUnion{1, 2, 3}
+	shards := shardCells(cells, 8)
+	if len(shards) != 1 || !reflect.DeepEqual(shards[0], cells) {
+		t.Errorf("shardCells() = %v, want a single shard containing every cell", shards)
+	}
+}
+
+func TestShardCellsSplits(t *testing.T) {
+	cells := make(s2.CellUnion, 10)
+	for i := range cells {
+		cells[i] = s2.CellID(i)
+	}
+
+	shards := shardCells(cells, 4)
+	if len(shards) != 3 {
+		t.Fatalf("len(shardCells()) = %d, want 3", len(shards))
+	}
+	if len(shards[0]) != 4 || len(shards[1]) != 4 || len(shards[2]) != 2 {
+		t.Errorf("shard sizes = %d, %d, %d, want 4, 4, 2", len(shards[0]), len(shards[1]), len(shards[2]))
+	}
+
+	var recombined s2.CellUnion
+	for _, shard := range shards {
+		recombined = append(recombined, shard...)
+	}
+	if !reflect.DeepEqual(recombined, cells) {
+		t.Errorf("shards recombined = %v, want %v", recombined, cells)
+	}
+}
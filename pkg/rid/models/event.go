@@ -0,0 +1,11 @@
+package models
+
+// ISAEvent describes the identification-service-area or flight change being
+// fanned out to subscribers, so the store can filter the affected
+// subscription set by each Subscription's Filter before notifying.
+type ISAEvent struct {
+	Kind             EventMask
+	OperatorID       string
+	AltitudeLoMeters float32
+	AltitudeHiMeters float32
+}
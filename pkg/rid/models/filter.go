@@ -0,0 +1,130 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"math"
+)
+
+// EventMask is a bitmask of the ISA/flight lifecycle events a subscriber
+// cares about. EventMask(0).Matches always reports false; EventMaskAll
+// matches every event type. Filter.EventMask treats its own zero value as
+// EventMaskAll instead, so existing subscriptions keep matching everything
+// after filters were introduced - see Filter.Matches.
+type EventMask uint8
+
+const (
+	EventISACreated EventMask = 1 << iota
+	EventISAUpdated
+	EventISADeleted
+	EventFlightCreated
+	EventFlightUpdated
+	EventFlightDeleted
+
+	EventMaskAll = EventISACreated | EventISAUpdated | EventISADeleted |
+		EventFlightCreated | EventFlightUpdated | EventFlightDeleted
+)
+
+// Matches reports whether e is included in the mask.
+func (m EventMask) Matches(e EventMask) bool {
+	return m&e != 0
+}
+
+// Filter narrows the ISA/flight events that should trigger a notification
+// for a Subscription, beyond the bare cell and time-window match.
+type Filter struct {
+	// AltitudeLoMeters and AltitudeHiMeters bound the altitude band the
+	// subscriber cares about. Both zero means no altitude restriction.
+	AltitudeLoMeters float32 `json:"altitude_lo_meters,omitempty"`
+	AltitudeHiMeters float32 `json:"altitude_hi_meters,omitempty"`
+
+	// OperatorIDs, if non-empty, restricts matches to ISAs/flights owned by
+	// one of these operators. Empty matches any operator.
+	OperatorIDs []string `json:"operator_ids,omitempty"`
+
+	// EventMask restricts matches to these event types. A zero value is
+	// treated as EventMaskAll by Matches, so subscriptions created before
+	// filters existed keep matching everything.
+	EventMask EventMask `json:"event_mask,omitempty"`
+}
+
+// Matches reports whether ev should notify this Filter's subscriber. A nil
+// Filter always matches, and so does a nil ev: callers that bump
+// notification_index for reasons other than an ISA/flight event (e.g. a
+// subscription's own cells changing) pass a nil ev to skip filtering.
+func (f *Filter) Matches(ev *ISAEvent) bool {
+	if f == nil || ev == nil {
+		return true
+	}
+
+	mask := f.EventMask
+	if mask == 0 {
+		mask = EventMaskAll
+	}
+	if !mask.Matches(ev.Kind) {
+		return false
+	}
+
+	if len(f.OperatorIDs) > 0 {
+		var found bool
+		for _, id := range f.OperatorIDs {
+			if id == ev.OperatorID {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if f.AltitudeLoMeters == 0 && f.AltitudeHiMeters == 0 {
+		return true
+	}
+	// A zero bound on one side alone means "unbounded on that side", not
+	// "bounded at zero" - a subscriber setting only AltitudeLoMeters wants
+	// everything above it, not a [0, 0] band.
+	lo, hi := f.AltitudeLoMeters, f.AltitudeHiMeters
+	if lo == 0 {
+		lo = -math.MaxFloat32
+	}
+	if hi == 0 {
+		hi = math.MaxFloat32
+	}
+	return ev.AltitudeHiMeters >= lo && ev.AltitudeLoMeters <= hi
+}
+
+// Value implements driver.Valuer so a Filter can be written to the
+// subscriptions.filter JSONB column. A nil Filter is stored as '{}', which
+// Matches treats as match-all.
+func (f *Filter) Value() (driver.Value, error) {
+	if f == nil {
+		return "{}", nil
+	}
+	b, err := json.Marshal(f)
+	if err != nil {
+		return nil, fmt.Errorf("marshaling subscription filter: %w", err)
+	}
+	return string(b), nil
+}
+
+// Scan implements sql.Scanner so a Filter can be read back from the
+// subscriptions.filter JSONB column.
+func (f *Filter) Scan(src interface{}) error {
+	var b []byte
+	switch v := src.(type) {
+	case nil:
+		return nil
+	case []byte:
+		b = v
+	case string:
+		b = []byte(v)
+	default:
+		return fmt.Errorf("unsupported type %T for subscription filter", src)
+	}
+	if len(b) == 0 {
+		return nil
+	}
+	return json.Unmarshal(b, f)
+}
@@ -0,0 +1,130 @@
+package models
+
+import "testing"
+
+func TestFilterMatchesNil(t *testing.T) {
+	var f *Filter
+	if !f.Matches(&ISAEvent{Kind: EventISACreated}) {
+		t.Error("nil Filter should match every event")
+	}
+	if !(&Filter{}).Matches(nil) {
+		t.Error("a nil event should always match, regardless of Filter")
+	}
+}
+
+func TestFilterMatchesEventMask(t *testing.T) {
+	f := &Filter{EventMask: EventISACreated | EventISADeleted}
+	if !f.Matches(&ISAEvent{Kind: EventISACreated}) {
+		t.Error("expected EventISACreated to match")
+	}
+	if f.Matches(&ISAEvent{Kind: EventISAUpdated}) {
+		t.Error("expected EventISAUpdated not to match")
+	}
+
+	// A zero EventMask is backward-compat for subscriptions predating
+	// filters, and must match every event type.
+	zero := &Filter{}
+	for _, kind := range []EventMask{EventISACreated, EventISAUpdated, EventISADeleted, EventFlightCreated, EventFlightUpdated, EventFlightDeleted} {
+		if !zero.Matches(&ISAEvent{Kind: kind}) {
+			t.Errorf("zero-value EventMask should match %v", kind)
+		}
+	}
+}
+
+func TestFilterMatchesOperatorIDs(t *testing.T) {
+	f := &Filter{OperatorIDs: []string{"op-1", "op-2"}}
+	if !f.Matches(&ISAEvent{Kind: EventISACreated, OperatorID: "op-2"}) {
+		t.Error("expected a listed operator to match")
+	}
+	if f.Matches(&ISAEvent{Kind: EventISACreated, OperatorID: "op-3"}) {
+		t.Error("expected an unlisted operator not to match")
+	}
+}
+
+func TestFilterMatchesAltitudeBand(t *testing.T) {
+	cases := []struct {
+		name string
+		f    *Filter
+		ev   *ISAEvent
+		want bool
+	}{
+		{
+			name: "no altitude restriction",
+			f:    &Filter{},
+			ev:   &ISAEvent{AltitudeLoMeters: 10000, AltitudeHiMeters: 20000},
+			want: true,
+		},
+		{
+			name: "within a two-sided band",
+			f:    &Filter{AltitudeLoMeters: 100, AltitudeHiMeters: 500},
+			ev:   &ISAEvent{AltitudeLoMeters: 200, AltitudeHiMeters: 300},
+			want: true,
+		},
+		{
+			name: "outside a two-sided band",
+			f:    &Filter{AltitudeLoMeters: 100, AltitudeHiMeters: 500},
+			ev:   &ISAEvent{AltitudeLoMeters: 600, AltitudeHiMeters: 700},
+			want: false,
+		},
+		{
+			name: "lo-only bound matches above it",
+			f:    &Filter{AltitudeLoMeters: 100},
+			ev:   &ISAEvent{AltitudeLoMeters: 1000, AltitudeHiMeters: 2000},
+			want: true,
+		},
+		{
+			name: "lo-only bound rejects below it",
+			f:    &Filter{AltitudeLoMeters: 100},
+			ev:   &ISAEvent{AltitudeLoMeters: 0, AltitudeHiMeters: 50},
+			want: false,
+		},
+		{
+			name: "hi-only bound matches below it",
+			f:    &Filter{AltitudeHiMeters: 500},
+			ev:   &ISAEvent{AltitudeLoMeters: 0, AltitudeHiMeters: 10},
+			want: true,
+		},
+		{
+			name: "hi-only bound rejects above it",
+			f:    &Filter{AltitudeHiMeters: 500},
+			ev:   &ISAEvent{AltitudeLoMeters: 600, AltitudeHiMeters: 700},
+			want: false,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			c.ev.Kind = EventISACreated
+			if got := c.f.Matches(c.ev); got != c.want {
+				t.Errorf("Matches() = %v, want %v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestFilterValueAndScanRoundTrip(t *testing.T) {
+	f := &Filter{AltitudeLoMeters: 10, OperatorIDs: []string{"op-1"}, EventMask: EventISACreated}
+	v, err := f.Value()
+	if err != nil {
+		t.Fatalf("Value() error: %v", err)
+	}
+
+	got := new(Filter)
+	if err := got.Scan(v); err != nil {
+		t.Fatalf("Scan() error: %v", err)
+	}
+	if got.AltitudeLoMeters != f.AltitudeLoMeters || len(got.OperatorIDs) != 1 || got.OperatorIDs[0] != "op-1" || got.EventMask != f.EventMask {
+		t.Errorf("round-tripped Filter = %+v, want %+v", got, f)
+	}
+}
+
+func TestFilterValueNil(t *testing.T) {
+	var f *Filter
+	v, err := f.Value()
+	if err != nil {
+		t.Fatalf("Value() error: %v", err)
+	}
+	if v != "{}" {
+		t.Errorf("Value() on nil Filter = %v, want \"{}\"", v)
+	}
+}
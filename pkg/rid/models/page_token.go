@@ -0,0 +1,42 @@
+package models
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	dssmodels "github.com/interuss/dss/pkg/models"
+)
+
+// SubscriptionPageToken is the keyset cursor for a paginated subscription
+// search: everything ordered strictly after (UpdatedAt, ID) in the
+// subscriptions table's (updated_at, id) order.
+type SubscriptionPageToken struct {
+	UpdatedAt time.Time    `json:"updated_at"`
+	ID        dssmodels.ID `json:"id"`
+}
+
+// Encode serializes t to the opaque string handed back to callers as a
+// nextPageToken.
+func (t SubscriptionPageToken) Encode() (string, error) {
+	b, err := json.Marshal(t)
+	if err != nil {
+		return "", fmt.Errorf("marshaling subscription page token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// DecodeSubscriptionPageToken parses a page token previously returned by
+// Encode.
+func DecodeSubscriptionPageToken(s string) (*SubscriptionPageToken, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("decoding subscription page token: %w", err)
+	}
+	t := new(SubscriptionPageToken)
+	if err := json.Unmarshal(b, t); err != nil {
+		return nil, fmt.Errorf("unmarshaling subscription page token: %w", err)
+	}
+	return t, nil
+}
@@ -0,0 +1,34 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	dssmodels "github.com/interuss/dss/pkg/models"
+)
+
+func TestSubscriptionPageTokenRoundTrip(t *testing.T) {
+	want := SubscriptionPageToken{
+		UpdatedAt: time.Date(2026, 7, 28, 12, 0, 0, 0, time.UTC),
+		ID:        dssmodels.ID("sub-1"),
+	}
+
+	encoded, err := want.Encode()
+	if err != nil {
+		t.Fatalf("Encode() error: %v", err)
+	}
+
+	got, err := DecodeSubscriptionPageToken(encoded)
+	if err != nil {
+		t.Fatalf("DecodeSubscriptionPageToken() error: %v", err)
+	}
+	if !got.UpdatedAt.Equal(want.UpdatedAt) || got.ID != want.ID {
+		t.Errorf("round-tripped token = %+v, want %+v", got, want)
+	}
+}
+
+func TestDecodeSubscriptionPageTokenInvalid(t *testing.T) {
+	if _, err := DecodeSubscriptionPageToken("not-valid-base64!!"); err == nil {
+		t.Error("expected an error decoding a malformed page token")
+	}
+}
@@ -0,0 +1,41 @@
+// Package models holds the RID domain types shared between the gRPC/HTTP
+// handlers and the store implementations in pkg/rid/cockroach.
+package models
+
+import (
+	"time"
+
+	dssmodels "github.com/interuss/dss/pkg/models"
+
+	"github.com/golang/geo/s2"
+	"github.com/lib/pq"
+)
+
+// Subscription represents a subscriber's interest in identification service
+// area changes within a set of cells, for the lifetime between StartTime
+// and EndTime.
+type Subscription struct {
+	ID                dssmodels.ID
+	Owner             dssmodels.Owner
+	URL               string
+	NotificationIndex int
+	Cells             s2.CellUnion
+	StartTime         *time.Time
+	EndTime           *time.Time
+	Version           dssmodels.Version
+
+	// Filter narrows which ISA/flight events actually trigger a
+	// notification to URL. A nil Filter matches every event in Cells, for
+	// backward compatibility with subscriptions created before filters
+	// existed.
+	Filter *Filter
+}
+
+// SetCells populates s.Cells from the raw cell IDs returned by a CRDB query.
+func (s *Subscription) SetCells(cids pq.Int64Array) {
+	cells := make(s2.CellUnion, len(cids))
+	for i, cid := range cids {
+		cells[i] = s2.CellID(cid)
+	}
+	s.Cells = cells
+}
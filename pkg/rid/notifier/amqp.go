@@ -0,0 +1,42 @@
+package notifier
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/streadway/amqp"
+)
+
+// AMQPDispatcher delivers notifications by publishing to an AMQP exchange,
+// using the full subscription URL as the routing key.
+type AMQPDispatcher struct {
+	channel  *amqp.Channel
+	exchange string
+}
+
+// NewAMQPDispatcher builds an AMQPDispatcher that publishes onto exchange
+// via channel.
+func NewAMQPDispatcher(channel *amqp.Channel, exchange string) *AMQPDispatcher {
+	return &AMQPDispatcher{channel: channel, exchange: exchange}
+}
+
+// Scheme implements Dispatcher.
+func (a *AMQPDispatcher) Scheme() string { return "amqp" }
+
+// Dispatch implements Dispatcher.
+func (a *AMQPDispatcher) Dispatch(ctx context.Context, n *Notification) error {
+	err := a.channel.Publish(
+		a.exchange,
+		n.URL,
+		false,
+		false,
+		amqp.Publishing{
+			ContentType: "application/json",
+			Body:        n.Payload,
+		},
+	)
+	if err != nil {
+		return Retryable(fmt.Errorf("publishing to amqp exchange %q: %w", a.exchange, err))
+	}
+	return nil
+}
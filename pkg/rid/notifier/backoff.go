@@ -0,0 +1,73 @@
+package notifier
+
+import (
+	"errors"
+	"math/rand"
+	"time"
+)
+
+// Backoff describes an exponential backoff with jitter policy for retrying
+// notification dispatch.
+type Backoff struct {
+	// Base is the delay before the first retry.
+	Base time.Duration
+	// Factor is the multiplier applied to the delay after each attempt.
+	Factor float64
+	// Cap is the maximum delay between attempts.
+	Cap time.Duration
+	// MaxTries is the total number of dispatch attempts, including the
+	// first. A MaxTries of 6 means up to 5 retries.
+	MaxTries int
+}
+
+// DefaultBackoff is the policy described by the RID notification dispatch
+// design: base 500ms, factor 2, capped at 30s, 6 attempts total.
+var DefaultBackoff = Backoff{
+	Base:     500 * time.Millisecond,
+	Factor:   2,
+	Cap:      30 * time.Second,
+	MaxTries: 6,
+}
+
+// Delays returns one value per attempt: 0 for the first attempt (no wait),
+// then the jittered delay to wait before each retry. It has len(MaxTries)
+// entries, computed eagerly so callers can range over it without spawning
+// a goroutine that would otherwise leak on early exit (success or a
+// non-retryable error) from the retry loop.
+func (b Backoff) Delays() []time.Duration {
+	delays := make([]time.Duration, b.MaxTries)
+	delay := b.Base
+	for i := range delays {
+		if i == 0 {
+			continue
+		}
+		delays[i] = time.Duration(rand.Int63n(int64(delay)))
+		delay = time.Duration(float64(delay) * b.Factor)
+		if delay > b.Cap {
+			delay = b.Cap
+		}
+	}
+	return delays
+}
+
+type retryableError struct {
+	err error
+}
+
+func (r *retryableError) Error() string { return r.err.Error() }
+func (r *retryableError) Unwrap() error { return r.err }
+
+// Retryable wraps err so that IsRetryable reports true for it, marking a
+// dispatch failure (5xx response, timeout) as safe to retry.
+func Retryable(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &retryableError{err: err}
+}
+
+// IsRetryable reports whether err was wrapped with Retryable.
+func IsRetryable(err error) bool {
+	var r *retryableError
+	return errors.As(err, &r)
+}
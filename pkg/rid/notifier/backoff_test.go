@@ -0,0 +1,58 @@
+package notifier
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBackoffDelaysLength(t *testing.T) {
+	b := Backoff{Base: 10 * time.Millisecond, Factor: 2, Cap: time.Second, MaxTries: 6}
+	delays := b.Delays()
+	if len(delays) != b.MaxTries {
+		t.Fatalf("len(Delays()) = %d, want %d", len(delays), b.MaxTries)
+	}
+	if delays[0] != 0 {
+		t.Errorf("delays[0] = %v, want 0 (first attempt has no wait)", delays[0])
+	}
+}
+
+func TestBackoffDelaysRespectCap(t *testing.T) {
+	b := Backoff{Base: 10 * time.Millisecond, Factor: 10, Cap: 50 * time.Millisecond, MaxTries: 8}
+	for i, d := range b.Delays() {
+		if d < 0 || d > b.Cap {
+			t.Errorf("delays[%d] = %v, want within [0, %v]", i, d, b.Cap)
+		}
+	}
+}
+
+func TestBackoffDelaysDeterministicLength(t *testing.T) {
+	// Regression test for the goroutine-leak fix: Delays must be a plain
+	// value callers can range over fully or abandon early (e.g. after a
+	// successful attempt) with nothing left running in the background.
+	b := DefaultBackoff
+	delays := b.Delays()
+	for i := range delays {
+		_ = delays[:i]
+	}
+	if len(delays) != DefaultBackoff.MaxTries {
+		t.Fatalf("len(Delays()) = %d, want %d", len(delays), DefaultBackoff.MaxTries)
+	}
+}
+
+func TestRetryable(t *testing.T) {
+	base := errors.New("boom")
+	if IsRetryable(base) {
+		t.Error("a plain error should not be retryable")
+	}
+	wrapped := Retryable(base)
+	if !IsRetryable(wrapped) {
+		t.Error("an error wrapped with Retryable should be retryable")
+	}
+	if !errors.Is(wrapped, base) {
+		t.Error("Retryable should preserve errors.Is semantics")
+	}
+	if Retryable(nil) != nil {
+		t.Error("Retryable(nil) should return nil")
+	}
+}
@@ -0,0 +1,36 @@
+package notifier
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Shopify/sarama"
+)
+
+// KafkaDispatcher delivers notifications by producing to a Kafka topic,
+// keyed on the subscription ID so per-subscriber ordering is preserved.
+type KafkaDispatcher struct {
+	producer sarama.SyncProducer
+	topic    string
+}
+
+// NewKafkaDispatcher builds a KafkaDispatcher that produces onto topic.
+func NewKafkaDispatcher(producer sarama.SyncProducer, topic string) *KafkaDispatcher {
+	return &KafkaDispatcher{producer: producer, topic: topic}
+}
+
+// Scheme implements Dispatcher.
+func (k *KafkaDispatcher) Scheme() string { return "kafka" }
+
+// Dispatch implements Dispatcher.
+func (k *KafkaDispatcher) Dispatch(ctx context.Context, n *Notification) error {
+	msg := &sarama.ProducerMessage{
+		Topic: k.topic,
+		Key:   sarama.StringEncoder(n.SubscriptionID.String()),
+		Value: sarama.ByteEncoder(n.Payload),
+	}
+	if _, _, err := k.producer.SendMessage(msg); err != nil {
+		return Retryable(fmt.Errorf("producing to kafka topic %q: %w", k.topic, err))
+	}
+	return nil
+}
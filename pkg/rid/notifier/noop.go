@@ -0,0 +1,24 @@
+package notifier
+
+import "context"
+
+// NoopDispatcher records every Notification it receives without delivering
+// it anywhere. It is intended for tests and for the "noop" URL scheme used
+// by subscriptions that don't want real callbacks.
+type NoopDispatcher struct {
+	Notifications []*Notification
+}
+
+// NewNoopDispatcher builds an empty NoopDispatcher.
+func NewNoopDispatcher() *NoopDispatcher {
+	return &NoopDispatcher{}
+}
+
+// Scheme implements Dispatcher.
+func (n *NoopDispatcher) Scheme() string { return "noop" }
+
+// Dispatch implements Dispatcher.
+func (n *NoopDispatcher) Dispatch(ctx context.Context, notification *Notification) error {
+	n.Notifications = append(n.Notifications, notification)
+	return nil
+}
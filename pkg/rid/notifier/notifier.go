@@ -0,0 +1,165 @@
+// Package notifier dispatches RID identification-service-area change
+// notifications to subscribers, selecting a delivery backend by the scheme
+// of the subscription's callback URL (https, amqp, kafka, or a no-op sink
+// for tests).
+package notifier
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+
+	dssmodels "github.com/interuss/dss/pkg/models"
+	ridmodels "github.com/interuss/dss/pkg/rid/models"
+
+	"github.com/dpjacques/clockwork"
+	"go.uber.org/zap"
+)
+
+// Notification is the payload delivered to a subscriber when the
+// identification service areas in their subscribed cells change.
+type Notification struct {
+	SubscriptionID    dssmodels.ID
+	URL               string
+	NotificationIndex int
+	Payload           []byte
+
+	// FirstAttempt is when MultiDispatcher.Dispatch first tried to deliver
+	// this Notification, before any retries. DLQ.Put persists it as-is, so
+	// the dead-letter row records when delivery was first attempted rather
+	// than when it was finally given up on.
+	FirstAttempt time.Time
+}
+
+// Dispatcher delivers a Notification to a single subscriber URL.
+//
+// Implementations should treat Dispatch as best-effort: transient failures
+// are retried by the caller according to the backoff policy in
+// NewMultiDispatcher, so Dispatch itself should not retry internally.
+type Dispatcher interface {
+	// Scheme is the URL scheme this Dispatcher handles, e.g. "https".
+	Scheme() string
+	// Dispatch delivers n, returning an error if delivery failed. Errors
+	// that are safe to retry (5xx responses, timeouts) should be wrapped
+	// with Retryable so the caller's backoff loop can tell them apart
+	// from permanent failures (e.g. a malformed URL).
+	Dispatch(ctx context.Context, n *Notification) error
+}
+
+// DLQ persists notifications that exhausted all retry attempts so that
+// operators can inspect and replay them later.
+type DLQ interface {
+	Put(ctx context.Context, owner dssmodels.Owner, n *Notification, lastErr error, retries int) error
+}
+
+// NotificationDispatcher is the top-level, pluggable entry point a
+// SubscriptionStore dispatches a batch of subscription notifications
+// through. MultiDispatcher is the only production implementation;
+// plugging in a fake satisfying this interface is enough to test a store
+// without a real MultiDispatcher.
+type NotificationDispatcher interface {
+	// DispatchAll delivers payload to every subscription in subs,
+	// returning any per-subscription errors that remain after retries and
+	// DLQ persistence.
+	DispatchAll(ctx context.Context, subs []*ridmodels.Subscription, payload []byte) []error
+}
+
+// MultiDispatcher fans a Notification out to the Dispatcher registered for
+// its URL's scheme, applying exponential backoff with jitter on retryable
+// errors before giving up and writing to the DLQ.
+type MultiDispatcher struct {
+	dispatchers map[string]Dispatcher
+	backoff     Backoff
+	dlq         DLQ
+	logger      *zap.Logger
+	clock       clockwork.Clock
+}
+
+// NewMultiDispatcher builds a MultiDispatcher that routes to d by scheme and
+// falls back to dlq when a Notification exhausts backoff.MaxTries.
+func NewMultiDispatcher(logger *zap.Logger, clock clockwork.Clock, backoff Backoff, dlq DLQ, d ...Dispatcher) *MultiDispatcher {
+	dispatchers := make(map[string]Dispatcher, len(d))
+	for _, dispatcher := range d {
+		dispatchers[dispatcher.Scheme()] = dispatcher
+	}
+	return &MultiDispatcher{
+		dispatchers: dispatchers,
+		backoff:     backoff,
+		dlq:         dlq,
+		logger:      logger,
+		clock:       clock,
+	}
+}
+
+// Dispatch routes n to the Dispatcher registered for its URL scheme and
+// retries retryable failures with backoff before persisting to the DLQ.
+func (m *MultiDispatcher) Dispatch(ctx context.Context, owner dssmodels.Owner, n *Notification) error {
+	n.FirstAttempt = m.clock.Now()
+
+	u, err := url.Parse(n.URL)
+	if err != nil {
+		return fmt.Errorf("parsing subscription url %q: %w", n.URL, err)
+	}
+
+	d, ok := m.dispatchers[u.Scheme]
+	if !ok {
+		return fmt.Errorf("no notifier registered for scheme %q", u.Scheme)
+	}
+
+	var lastErr error
+	retries := 0
+	for attempt, wait := range m.backoff.Delays() {
+		if wait > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(wait):
+			}
+		}
+
+		lastErr = d.Dispatch(ctx, n)
+		if lastErr == nil {
+			return nil
+		}
+		// attempt 0 is the first attempt, not a retry; only count the ones
+		// after it so a dead-lettered Notification's retries matches
+		// MaxTries' doc (MaxTries of 6 means up to 5 retries), not the
+		// total number of failed attempts.
+		if attempt > 0 {
+			retries++
+		}
+		if !IsRetryable(lastErr) {
+			break
+		}
+	}
+
+	m.logger.Warn("exhausted notification retries, writing to dead-letter queue",
+		zap.String("subscription_id", n.SubscriptionID.String()),
+		zap.String("url", n.URL),
+		zap.Int("retries", retries),
+		zap.Error(lastErr))
+
+	if m.dlq == nil {
+		return lastErr
+	}
+	return m.dlq.Put(ctx, owner, n, lastErr, retries)
+}
+
+// DispatchAll delivers payload to every subscription, collecting and returning any
+// per-subscription errors that remain after retries and DLQ persistence.
+func (m *MultiDispatcher) DispatchAll(ctx context.Context, subs []*ridmodels.Subscription, payload []byte) []error {
+	var errs []error
+	for _, sub := range subs {
+		n := &Notification{
+			SubscriptionID:    sub.ID,
+			URL:               sub.URL,
+			NotificationIndex: sub.NotificationIndex,
+			Payload:           payload,
+		}
+		if err := m.Dispatch(ctx, sub.Owner, n); err != nil {
+			errs = append(errs, fmt.Errorf("dispatching to subscription %s: %w", sub.ID, err))
+		}
+	}
+	return errs
+}
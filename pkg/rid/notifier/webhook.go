@@ -0,0 +1,52 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// WebhookDispatcher delivers notifications via an HTTPS POST to the
+// subscription's URL.
+type WebhookDispatcher struct {
+	client *http.Client
+}
+
+// NewWebhookDispatcher builds a WebhookDispatcher with a bounded per-request
+// timeout.
+func NewWebhookDispatcher(timeout time.Duration) *WebhookDispatcher {
+	return &WebhookDispatcher{
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+// Scheme implements Dispatcher.
+func (w *WebhookDispatcher) Scheme() string { return "https" }
+
+// Dispatch implements Dispatcher.
+func (w *WebhookDispatcher) Dispatch(ctx context.Context, n *Notification) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.URL, bytes.NewReader(n.Payload))
+	if err != nil {
+		return fmt.Errorf("building webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		if ctx.Err() == nil {
+			return Retryable(err)
+		}
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 500 {
+		return Retryable(fmt.Errorf("webhook %s returned %s", n.URL, resp.Status))
+	}
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("webhook %s returned %s", n.URL, resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+// Package cache maintains an in-memory count of subscriptions per
+// (owner, cell) pair, so that MaxSubscriptionCountInCellsByOwner can answer
+// from memory instead of running an aggregation query against CRDB on every
+// subscription insert.
+package cache
+
+import (
+	"sync"
+
+	dssmodels "github.com/interuss/dss/pkg/models"
+
+	"github.com/golang/geo/s2"
+)
+
+// Mode selects how SubscriptionCounter stays in sync with CRDB.
+type Mode string
+
+const (
+	// ModeOff disables the cache; callers should fall back to the CRDB
+	// aggregation query.
+	ModeOff Mode = "off"
+	// ModeMemory keeps the cache updated inline by the Insert/Update/Delete
+	// paths, with a periodic re-seed from CRDB (see NewCounterFromDB) as the
+	// only correction for sibling-node writes.
+	ModeMemory Mode = "memory"
+	// ModeChangefeed instead subscribes to a CRDB CHANGEFEED on the
+	// subscriptions table and applies every row change from it, this node's
+	// own writes included, so the cache stays correct when a sibling DSS
+	// node mutates a row this node doesn't know about.
+	ModeChangefeed Mode = "changefeed"
+)
+
+// SubscriptionCounter tracks, per owner, how many live subscriptions touch
+// each cell. It is safe for concurrent use.
+type SubscriptionCounter struct {
+	mu     sync.RWMutex
+	counts map[dssmodels.Owner]map[s2.CellID]int32
+}
+
+// NewSubscriptionCounter returns an empty SubscriptionCounter. Callers
+// should follow with Seed to populate it from the current CRDB state
+// before serving traffic.
+func NewSubscriptionCounter() *SubscriptionCounter {
+	return &SubscriptionCounter{
+		counts: make(map[dssmodels.Owner]map[s2.CellID]int32),
+	}
+}
+
+// Seed replaces the counter's state with counts derived from rows, where
+// each entry is the cell union of one live subscription belonging to
+// owner. It is called at startup, and again on each reconcile, with every
+// live subscription streamed from CRDB.
+func (s *SubscriptionCounter) Seed(rows map[dssmodels.Owner][]s2.CellUnion) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.counts = make(map[dssmodels.Owner]map[s2.CellID]int32, len(rows))
+	for owner, cellUnions := range rows {
+		for _, cells := range cellUnions {
+			s.addLocked(owner, cells)
+		}
+	}
+}
+
+// OnInsert records the cells of a newly inserted subscription belonging to
+// owner.
+func (s *SubscriptionCounter) OnInsert(owner dssmodels.Owner, cells s2.CellUnion) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.addLocked(owner, cells)
+}
+
+// OnDelete removes the cells of a deleted subscription belonging to owner.
+func (s *SubscriptionCounter) OnDelete(owner dssmodels.Owner, cells s2.CellUnion) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.subLocked(owner, cells)
+}
+
+// OnUpdate adjusts counts for owner from a subscription whose cell union
+// changed from oldCells to newCells.
+func (s *SubscriptionCounter) OnUpdate(owner dssmodels.Owner, oldCells, newCells s2.CellUnion) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.subLocked(owner, oldCells)
+	s.addLocked(owner, newCells)
+}
+
+// Max returns the highest per-cell subscription count owner has across
+// cells.
+func (s *SubscriptionCounter) Max(owner dssmodels.Owner, cells s2.CellUnion) int32 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	byCell := s.counts[owner]
+	var max int32
+	for _, cell := range cells {
+		if n := byCell[cell]; n > max {
+			max = n
+		}
+	}
+	return max
+}
+
+func (s *SubscriptionCounter) addLocked(owner dssmodels.Owner, cells s2.CellUnion) {
+	byCell, ok := s.counts[owner]
+	if !ok {
+		byCell = make(map[s2.CellID]int32, len(cells))
+		s.counts[owner] = byCell
+	}
+	for _, cell := range cells {
+		byCell[cell]++
+	}
+}
+
+func (s *SubscriptionCounter) subLocked(owner dssmodels.Owner, cells s2.CellUnion) {
+	byCell, ok := s.counts[owner]
+	if !ok {
+		return
+	}
+	for _, cell := range cells {
+		byCell[cell]--
+		if byCell[cell] <= 0 {
+			delete(byCell, cell)
+		}
+	}
+	if len(byCell) == 0 {
+		delete(s.counts, owner)
+	}
+}
@@ -0,0 +1,74 @@
+package cache
+
+import (
+	"testing"
+
+	dssmodels "github.com/interuss/dss/pkg/models"
+
+	"github.com/golang/geo/s2"
+)
+
+func TestSubscriptionCounterInsertAndDelete(t *testing.T) {
+	c := NewSubscriptionCounter()
+	owner := dssmodels.Owner("owner-1")
+	cells := s2.CellUnion{1, 2, 3}
+
+	c.OnInsert(owner, cells)
+	if got := c.Max(owner, cells); got != 1 {
+		t.Errorf("Max() after one insert = %d, want 1", got)
+	}
+
+	c.OnInsert(owner, s2.CellUnion{2, 3, 4})
+	if got := c.Max(owner, s2.CellUnion{2, 3}); got != 2 {
+		t.Errorf("Max() for doubly-inserted cells = %d, want 2", got)
+	}
+	if got := c.Max(owner, s2.CellUnion{1}); got != 1 {
+		t.Errorf("Max() for singly-inserted cell = %d, want 1", got)
+	}
+
+	c.OnDelete(owner, cells)
+	if got := c.Max(owner, s2.CellUnion{1}); got != 0 {
+		t.Errorf("Max() after deleting the only subscription touching cell 1 = %d, want 0", got)
+	}
+	if got := c.Max(owner, s2.CellUnion{2, 3, 4}); got != 1 {
+		t.Errorf("Max() for the surviving subscription = %d, want 1", got)
+	}
+}
+
+func TestSubscriptionCounterUpdateMovesCells(t *testing.T) {
+	c := NewSubscriptionCounter()
+	owner := dssmodels.Owner("owner-1")
+
+	c.OnInsert(owner, s2.CellUnion{1, 2})
+	c.OnUpdate(owner, s2.CellUnion{1, 2}, s2.CellUnion{2, 3})
+
+	if got := c.Max(owner, s2.CellUnion{1}); got != 0 {
+		t.Errorf("Max() for a cell the subscription moved out of = %d, want 0", got)
+	}
+	if got := c.Max(owner, s2.CellUnion{2}); got != 1 {
+		t.Errorf("Max() for a retained cell = %d, want 1", got)
+	}
+	if got := c.Max(owner, s2.CellUnion{3}); got != 1 {
+		t.Errorf("Max() for a newly added cell = %d, want 1", got)
+	}
+}
+
+func TestSubscriptionCounterUpdateWithNilOldCellsBehavesLikeInsert(t *testing.T) {
+	// Regression test: applyChangefeedRow relies on OnUpdate(owner, nil,
+	// cells) being equivalent to OnInsert for changefeed rows with no prior
+	// value (genuine inserts).
+	c := NewSubscriptionCounter()
+	owner := dssmodels.Owner("owner-1")
+
+	c.OnUpdate(owner, nil, s2.CellUnion{1, 2})
+	if got := c.Max(owner, s2.CellUnion{1, 2}); got != 1 {
+		t.Errorf("Max() after OnUpdate with nil oldCells = %d, want 1", got)
+	}
+}
+
+func TestSubscriptionCounterMaxUnknownOwner(t *testing.T) {
+	c := NewSubscriptionCounter()
+	if got := c.Max(dssmodels.Owner("nobody"), s2.CellUnion{1}); got != 0 {
+		t.Errorf("Max() for an owner with no subscriptions = %d, want 0", got)
+	}
+}
@@ -0,0 +1,22 @@
+package cache
+
+import "flag"
+
+// subCountCacheFlagName is the flag RegisterFlags registers.
+const subCountCacheFlagName = "sub-count-cache"
+
+// RegisterFlags registers --sub-count-cache on fs and returns a getter for
+// the Mode it selects, valid once fs.Parse has run. Binding to a
+// caller-supplied fs (typically flag.CommandLine), rather than a
+// package-level flag.String at import time, keeps the flag scoped to the
+// binary that wants it and avoids a panic from double-registration if this
+// package is imported more than once under test.
+func RegisterFlags(fs *flag.FlagSet) func() Mode {
+	s := fs.String(
+		subCountCacheFlagName, string(ModeMemory),
+		"one of off|memory|changefeed: how MaxSubscriptionCountInCellsByOwner is served. "+
+			"'off' falls back to the CRDB aggregation query, 'memory' serves from an "+
+			"in-process counter updated inline by mutations, and 'changefeed' instead "+
+			"keeps that counter in sync, sibling nodes included, via a CRDB CHANGEFEED.")
+	return func() Mode { return Mode(*s) }
+}
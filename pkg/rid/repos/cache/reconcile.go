@@ -0,0 +1,251 @@
+package cache
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	dssmodels "github.com/interuss/dss/pkg/models"
+
+	"github.com/golang/geo/s2"
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+)
+
+// DefaultReconcileInterval is a default for the interval callers pass to
+// NewCounterFromDB, which is how often RunPeriodicReconcile re-seeds the
+// counter in ModeMemory.
+const DefaultReconcileInterval = 30 * time.Second
+
+// Reconciler keeps a SubscriptionCounter consistent with CRDB across
+// multiple DSS nodes, either by following a CHANGEFEED on the subscriptions
+// table or by periodically re-seeding from a full scan.
+type Reconciler struct {
+	db      *sql.DB
+	counter *SubscriptionCounter
+	logger  *zap.Logger
+}
+
+// NewReconciler builds a Reconciler that keeps counter in sync using db.
+func NewReconciler(db *sql.DB, counter *SubscriptionCounter, logger *zap.Logger) *Reconciler {
+	return &Reconciler{db: db, counter: counter, logger: logger}
+}
+
+// SeedFromCRDB populates counter from every live subscription row. It
+// should run once at startup before the counter serves traffic.
+func (r *Reconciler) SeedFromCRDB(ctx context.Context) error {
+	rows, err := r.db.QueryContext(ctx, `SELECT owner, cells FROM subscriptions WHERE ends_at >= transaction_timestamp()`)
+	if err != nil {
+		return fmt.Errorf("scanning subscriptions to seed counter: %w", err)
+	}
+	defer rows.Close()
+
+	byOwner := make(map[dssmodels.Owner][]s2.CellUnion)
+	for rows.Next() {
+		var owner dssmodels.Owner
+		cids := pq.Int64Array{}
+		if err := rows.Scan(&owner, &cids); err != nil {
+			return err
+		}
+		cells := make(s2.CellUnion, len(cids))
+		for i, cid := range cids {
+			cells[i] = s2.CellID(cid)
+		}
+		byOwner[owner] = append(byOwner[owner], cells)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	r.counter.Seed(byOwner)
+	return nil
+}
+
+// RunPeriodicReconcile re-seeds counter from CRDB every interval until ctx
+// is done. It is the fallback for deployments that run ModeMemory without a
+// CHANGEFEED: drift from sibling-node writes is bounded by interval instead
+// of corrected immediately.
+func (r *Reconciler) RunPeriodicReconcile(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := r.SeedFromCRDB(ctx); err != nil {
+				r.logger.Error("reconciling subscription count cache", zap.Error(err))
+			}
+		}
+	}
+}
+
+// FollowChangefeed consumes a CRDB CHANGEFEED on the subscriptions table
+// and applies each row change to counter, so multi-node DSS deployments
+// stay consistent even when a sibling node mutates the table. changes is
+// expected to come from StreamChangefeed's `EXPERIMENTAL CHANGEFEED FOR
+// subscriptions WITH diff` query, one row per change: the WITH diff option is required
+// so each update row carries the cells the row had before the change in
+// PriorCells, not just the cells it ends up with.
+func (r *Reconciler) FollowChangefeed(ctx context.Context, changes <-chan ChangefeedRow) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case row, ok := <-changes:
+			if !ok {
+				return
+			}
+			r.applyChangefeedRow(row)
+		}
+	}
+}
+
+// ChangefeedRow is a single decoded row emitted by the subscriptions
+// CHANGEFEED, which must be created WITH diff.
+type ChangefeedRow struct {
+	Owner dssmodels.Owner
+	Cells s2.CellUnion
+	// PriorCells is the row's cells before this change, decoded from the
+	// changefeed's "before" value. It is nil both for a genuine insert
+	// (there was no prior row) and for a Deleted row (PriorCells isn't
+	// used in that case); either way OnUpdate with a nil oldCells behaves
+	// like OnInsert, so callers don't need to tell the two apart.
+	PriorCells s2.CellUnion
+	Deleted    bool
+}
+
+func (r *Reconciler) applyChangefeedRow(row ChangefeedRow) {
+	if row.Deleted {
+		r.counter.OnDelete(row.Owner, row.Cells)
+		return
+	}
+	r.counter.OnUpdate(row.Owner, row.PriorCells, row.Cells)
+}
+
+// changefeedCols is the shape of the "after"/"before" JSON object CRDB emits
+// for each subscriptions row in a core CHANGEFEED.
+type changefeedCols struct {
+	Owner dssmodels.Owner `json:"owner"`
+	Cells []int64         `json:"cells"`
+}
+
+func (c changefeedCols) cellUnion() s2.CellUnion {
+	cells := make(s2.CellUnion, len(c.Cells))
+	for i, cid := range c.Cells {
+		cells[i] = s2.CellID(cid)
+	}
+	return cells
+}
+
+// decodeChangefeedRow parses the JSON "value" column of a core CHANGEFEED
+// row created WITH diff into a ChangefeedRow.
+func decodeChangefeedRow(value []byte) (ChangefeedRow, error) {
+	var payload struct {
+		After  *changefeedCols `json:"after"`
+		Before *changefeedCols `json:"before"`
+	}
+	if err := json.Unmarshal(value, &payload); err != nil {
+		return ChangefeedRow{}, fmt.Errorf("decoding changefeed row: %w", err)
+	}
+	if payload.After == nil {
+		row := ChangefeedRow{Deleted: true}
+		if payload.Before != nil {
+			row.Owner = payload.Before.Owner
+			row.Cells = payload.Before.cellUnion()
+		}
+		return row, nil
+	}
+	row := ChangefeedRow{
+		Owner: payload.After.Owner,
+		Cells: payload.After.cellUnion(),
+	}
+	if payload.Before != nil {
+		row.PriorCells = payload.Before.cellUnion()
+	}
+	return row, nil
+}
+
+// StreamChangefeed runs `EXPERIMENTAL CHANGEFEED FOR subscriptions WITH
+// diff` against db and decodes each emitted row onto the returned channel,
+// until ctx is done or the underlying query fails. The error channel
+// receives at most one value, after which both channels are closed.
+func StreamChangefeed(ctx context.Context, db *sql.DB) (<-chan ChangefeedRow, <-chan error) {
+	out := make(chan ChangefeedRow)
+	errc := make(chan error, 1)
+	go func() {
+		defer close(out)
+		defer close(errc)
+		rows, err := db.QueryContext(ctx, `EXPERIMENTAL CHANGEFEED FOR subscriptions WITH diff`)
+		if err != nil {
+			errc <- fmt.Errorf("starting subscriptions changefeed: %w", err)
+			return
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var table, key string
+			var value []byte
+			if err := rows.Scan(&table, &key, &value); err != nil {
+				errc <- fmt.Errorf("scanning changefeed row: %w", err)
+				return
+			}
+			row, err := decodeChangefeedRow(value)
+			if err != nil {
+				errc <- err
+				return
+			}
+			select {
+			case out <- row:
+			case <-ctx.Done():
+				return
+			}
+		}
+		if err := rows.Err(); err != nil {
+			errc <- err
+		}
+	}()
+	return out, errc
+}
+
+// NewCounterFromDB builds a SubscriptionCounter appropriate for mode and
+// brings it up to date with db before returning it, so
+// MaxSubscriptionCountInCellsByOwner never under-reports against a cold
+// cache. ModeOff returns a nil counter. ModeMemory additionally runs
+// RunPeriodicReconcile against db every interval as a fallback against
+// sibling-node drift; ModeChangefeed instead follows a live CHANGEFEED,
+// restarting the stream with a fresh reconcile if it ever errors out. Both
+// background loops run until ctx is done.
+func NewCounterFromDB(ctx context.Context, db *sql.DB, mode Mode, interval time.Duration, logger *zap.Logger) (*SubscriptionCounter, error) {
+	if mode == ModeOff {
+		return nil, nil
+	}
+
+	counter := NewSubscriptionCounter()
+	r := NewReconciler(db, counter, logger)
+	if err := r.SeedFromCRDB(ctx); err != nil {
+		return nil, fmt.Errorf("seeding subscription count cache: %w", err)
+	}
+
+	switch mode {
+	case ModeMemory:
+		go r.RunPeriodicReconcile(ctx, interval)
+	case ModeChangefeed:
+		go func() {
+			for ctx.Err() == nil {
+				changes, errc := StreamChangefeed(ctx, db)
+				r.FollowChangefeed(ctx, changes)
+				if err := <-errc; err != nil && ctx.Err() == nil {
+					logger.Error("subscription changefeed stream ended, reseeding and retrying", zap.Error(err))
+					if err := r.SeedFromCRDB(ctx); err != nil {
+						logger.Error("reseeding subscription count cache after changefeed error", zap.Error(err))
+					}
+				}
+			}
+		}()
+	}
+
+	return counter, nil
+}